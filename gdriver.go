@@ -103,10 +103,35 @@ type DriverInterface interface {
 	Identity(int) string
 }
 
+// Prober is an optional interface a driver can implement to report whether it
+// is actually usable right now, for example because a kernel module or an
+// external service it depends on isn't present. When present, it is consulted
+// by default selection so that an unavailable driver is skipped in favour of
+// one that is.
+type Prober interface {
+	Probe() error
+}
+
+// Initializer is an optional interface a driver instance can implement so
+// that NewSingleton can hand it its construction options. It is detected by
+// type assertion, not required by DriverInterface, since most drivers don't
+// need it.
+type Initializer interface {
+	Init(opts ...any) error
+}
+
+// Closer is an optional interface a driver instance can implement so that
+// Shutdown knows how to release whatever it acquired during Init.
+type Closer interface {
+	Close() error
+}
+
 // driverMember is used internally to hold information about a driver. This helps
 // make things a bit simpler. Note that the groupname and driver name will be stored
 // as passed without case conversion. The key, however, is groupname.drivername
 // in the library and the key is created by using the function libraryKey(...)
+// Singleton is only populated once NewSingleton has been called for this
+// driver; until then it stays nil.
 type driverMember struct {
 	Group     string
 	Name      string
@@ -115,14 +140,91 @@ type driverMember struct {
 	Singleton interface{}
 }
 
+// EventOp describes what kind of change a Watch subscriber is being told about.
+type EventOp int
+
+// These constants identify the operation carried by an Event passed to a
+// Watch() subscriber.
+const (
+	EventRegister EventOp = iota
+	EventUnregister
+	EventReplace
+	EventDefaultChanged
+)
+
+// Event is delivered to subscribers registered with Watch whenever a driver
+// is registered, unregistered, replaced, or has its default status changed.
+type Event struct {
+	Op    EventOp
+	Group string
+	Name  string
+}
+
+// watcher holds a single subscription made through Watch. An empty Group means
+// the subscriber wants events for every group.
+type watcher struct {
+	group string
+	fn    func(event Event)
+}
+
 // The main storage, global, for the driver data. The mutex must be locked/unlocked
 // before any action in order to stop goroutines from colliding.
 var (
-	driverLibrary map[string]*driverMember
-	driverMu      sync.Mutex
-	isInitialised bool
+	driverLibrary  map[string]*driverMember
+	driverMu       sync.RWMutex
+	isInitialised  bool
+	watchers       []*watcher
+	driverLocks    = newKeyLocker()
+	groupPriority  = make(map[string][]string)
+	singletonOrder []string
 )
 
+// SetPriority records an ordered fallback list of driver names for groupName,
+// used by NewDefault when no driver has been explicitly marked as the default
+// with Default(). The first registered, available driver in order wins. This
+// mirrors graphdriver's hard-coded priority list (e.g. preferring "aufs" over
+// "overlay2" over "vfs") but lets callers configure it per group instead of
+// baking it into the library.
+func SetPriority(groupName string, order []string) {
+	driverMu.Lock()
+	defer driverMu.Unlock()
+	groupPriority[strings.ToLower(groupName)] = order
+}
+
+// Watch registers fn to be called whenever a driver changes in groupName. Pass
+// an empty groupName to receive events for every group. Subscribers are
+// called synchronously, in the goroutine that made the change, after that
+// change has released driverMu, so fn is free to call straight back into
+// gdriver (IsRegistered, New, another Register, ...) without deadlocking. It
+// should still not block for long, since it runs in the caller's goroutine.
+func Watch(groupName string, fn func(event Event)) {
+	driverMu.Lock()
+	defer driverMu.Unlock()
+	watchers = append(watchers, &watcher{group: groupName, fn: fn})
+}
+
+// watchersFor collects the callbacks subscribed to event's group (or to all
+// groups), without calling them. It must be called with driverMu already
+// held; the callbacks themselves must be invoked only after driverMu has been
+// released, since sync.RWMutex isn't reentrant and a subscriber reacting to
+// the event is likely to call straight back into gdriver.
+func watchersFor(event Event) []func(Event) {
+	var fns []func(Event)
+	for _, w := range watchers {
+		if w.group == "" || strings.EqualFold(w.group, event.Group) {
+			fns = append(fns, w.fn)
+		}
+	}
+	return fns
+}
+
+// notify calls every fn in fns with event. Callers must not hold driverMu.
+func notify(event Event, fns []func(Event)) {
+	for _, fn := range fns {
+		fn(event)
+	}
+}
+
 // Register a new driver into a group. The driver must be able to resolve the name
 // by the Identity() function. Internally, all groups and drivers are stored in
 // lowercase and separated by a period: e.g. "SQL","MySQL" will get stored with the
@@ -141,30 +243,113 @@ func Register(groupName string, newDriver DriverInterface) {
 		Default: false}
 
 	driverMu.Lock()
-	defer driverMu.Unlock()
 	if !isInitialised {
 		driverLibrary = make(map[string]*driverMember)
 	}
 	driverKey := libraryKey(groupName, driverName)
 
 	if _, ok := driverLibrary[driverKey]; ok {
+		driverMu.Unlock()
 		panic("Driver '" + driverKey + "' already exists")
 	}
 	driverLibrary[driverKey] = member
 	isInitialised = true
 
-	return
+	event := Event{Op: EventRegister, Group: groupName, Name: driverName}
+	fns := watchersFor(event)
+	driverMu.Unlock()
+
+	notify(event, fns)
+}
+
+// Unregister removes a previously registered driver from a group. It reports
+// whether a driver was actually found and removed. This allows a driver
+// provider (e.g. a hot-reloadable plugin) to pull itself out of the registry
+// at runtime instead of leaving a stale entry behind.
+//
+// Unregister refuses to remove a driver that has a live NewSingleton instance
+// (it returns false, leaving the driver registered) so that instance can't be
+// deleted out from under Shutdown without ever being Close()'d. Call Shutdown
+// on the group first to close its singletons, then Unregister.
+func Unregister(groupName, driverName string) bool {
+	driverKey := libraryKey(groupName, driverName)
+
+	driverMu.RLock()
+	initialised := isInitialised
+	_, ok := driverLibrary[driverKey]
+	driverMu.RUnlock()
+	if !initialised || !ok {
+		return false
+	}
+
+	// Take the per-key lock before committing to the delete, the same lock
+	// NewSingleton holds across its build-and-commit, so we can't race a
+	// concurrent NewSingleton into deleting out from under a Singleton that
+	// is about to be stamped onto this driverMember.
+	driverLocks.Lock(driverKey)
+	defer driverLocks.Unlock(driverKey)
+
+	driverMu.Lock()
+
+	member, ok := driverLibrary[driverKey]
+	if !ok || member.Singleton != nil {
+		driverMu.Unlock()
+		return false
+	}
+
+	delete(driverLibrary, driverKey)
+	event := Event{Op: EventUnregister, Group: groupName, Name: driverName}
+	fns := watchersFor(event)
+	driverMu.Unlock()
+
+	notify(event, fns)
+	return true
+}
+
+// Replace swaps the driver backing an existing group/name registration for
+// newDriver, or registers it if it isn't already present. This is the
+// hot-swap counterpart to Register, letting a plugin reload a new version of
+// itself without callers having to Unregister first.
+func Replace(groupName string, newDriver DriverInterface) {
+	driverName := newDriver.Identity(IdentityName)
+	if driverName == "" || driverName == DefaultSelection {
+		panic("Driver did not supply a valid name")
+	}
+
+	driverMu.Lock()
+
+	if !isInitialised {
+		driverLibrary = make(map[string]*driverMember)
+		isInitialised = true
+	}
+
+	driverKey := libraryKey(groupName, driverName)
+	if existing, ok := driverLibrary[driverKey]; ok {
+		existing.Driver = newDriver
+	} else {
+		driverLibrary[driverKey] = &driverMember{
+			Name:   driverName,
+			Group:  groupName,
+			Driver: newDriver,
+		}
+	}
+
+	event := Event{Op: EventReplace, Group: groupName, Name: driverName}
+	fns := watchersFor(event)
+	driverMu.Unlock()
+
+	notify(event, fns)
 }
 
 // IsRegistered will determine if the group and driver name is valid
 func IsRegistered(groupName, driverName string) (found bool) {
+	driverMu.RLock()
+	defer driverMu.RUnlock()
+
 	if !isInitialised {
 		return false
 	}
 
-	driverMu.Lock()
-	defer driverMu.Unlock()
-
 	_, found = driverLibrary[libraryKey(groupName, driverName)]
 	return found
 }
@@ -173,8 +358,8 @@ func IsRegistered(groupName, driverName string) (found bool) {
 // If you want the default name you must look it up with the GetDefaultName function.
 // This allows you to call New and ID from anywhere you want.
 func GetDriver(groupName, driverName string) (DriverInterface, error) {
-	driverMu.Lock()
-	defer driverMu.Unlock()
+	driverMu.RLock()
+	defer driverMu.RUnlock()
 	if driverInstance, ok := driverLibrary[libraryKey(groupName, driverName)]; ok {
 		return driverInstance.Driver, nil
 	}
@@ -193,7 +378,10 @@ func MustNewDefault(groupName string) interface{} {
 
 // New will call the driver's New() function and return a new instance of the driver class
 func New(groupName, driverName string) (interface{}, error) {
-	if !isInitialised {
+	driverMu.RLock()
+	initialised := isInitialised
+	driverMu.RUnlock()
+	if !initialised {
 		return nil, errors.New("Library is not initialised")
 	}
 
@@ -201,9 +389,6 @@ func New(groupName, driverName string) (interface{}, error) {
 		return newDefault(groupName)
 	}
 
-	driverMu.Lock()
-	defer driverMu.Unlock()
-
 	return findDriver(groupName, driverName)
 }
 
@@ -220,34 +405,138 @@ func MustNew(groupName, driverName string) interface{} {
 // newDefault will find a driver in the group that is either unique or is marked as a default. This is called by New()
 // when the driverName indicates default
 func newDefault(groupName string) (interface{}, error) {
+	return findDefaultDriver(groupName)
+}
+
+// NewSingleton builds a driver instance the first time it's called for a
+// given group/name and caches it in that driverMember's Singleton field;
+// every later call returns the same cached instance instead of building a
+// new one. opts is passed through to Init on the instance if it implements
+// Initializer. Construction and Init are serialised per-key so concurrent
+// callers can't race to initialise the same singleton twice, while unrelated
+// groups/names are unaffected.
+func NewSingleton(groupName, driverName string, opts ...any) (interface{}, error) {
+	driverKey := libraryKey(groupName, driverName)
+
+	driverMu.RLock()
+	initialised := isInitialised
+	member, ok := driverLibrary[driverKey]
+	driverMu.RUnlock()
+	if !initialised {
+		return nil, errors.New("Library is not initialised")
+	}
+	if !ok {
+		return nil, errors.New("Invalid driver: " + groupName + ":" + driverName)
+	}
+
+	driverLocks.Lock(driverKey)
+	defer driverLocks.Unlock(driverKey)
+
+	if member.Singleton != nil {
+		return member.Singleton, nil
+	}
+
+	instance := member.Driver.New()
+	if initializer, ok := instance.(Initializer); ok {
+		if err := initializer.Init(opts...); err != nil {
+			return nil, err
+		}
+	}
 
 	driverMu.Lock()
-	defer driverMu.Unlock()
+	member.Singleton = instance
+	singletonOrder = append(singletonOrder, driverKey)
+	driverMu.Unlock()
 
-	return findDefaultDriver(groupName)
+	return instance, nil
+}
+
+// Shutdown closes every singleton that has been created in groupName via
+// NewSingleton, in the reverse of the order they were created, and clears
+// them so a later NewSingleton call builds a fresh instance. Singletons that
+// don't implement Closer are simply dropped. It returns the first error
+// encountered from Close, but still attempts every singleton in the group.
+func Shutdown(groupName string) error {
+	lname := strings.ToLower(groupName) + NameSeparator
+
+	driverMu.RLock()
+	var keys []string
+	for _, key := range singletonOrder {
+		if strings.HasPrefix(key, lname) {
+			keys = append(keys, key)
+		}
+	}
+	driverMu.RUnlock()
+
+	var firstErr error
+	for i := len(keys) - 1; i >= 0; i-- {
+		key := keys[i]
+
+		driverLocks.Lock(key)
+		driverMu.Lock()
+		var singleton interface{}
+		if member, ok := driverLibrary[key]; ok {
+			singleton = member.Singleton
+			member.Singleton = nil
+		}
+		singletonOrder = removeSingletonKey(singletonOrder, key)
+		driverMu.Unlock()
+		driverLocks.Unlock(key)
+
+		if closer, ok := singleton.(Closer); ok {
+			if err := closer.Close(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// removeSingletonKey returns order with the first occurrence of key removed.
+// Callers must hold driverMu for writing.
+func removeSingletonKey(order []string, key string) []string {
+	for i, k := range order {
+		if k == key {
+			return append(order[:i], order[i+1:]...)
+		}
+	}
+	return order
 }
 
 // Default will make sure that only ONE driver is made a default
 func Default(groupName, driverName string) (found bool) {
-	// It must be initialised AND the driver name can't be what we use as a default driver name
-	if !isInitialised || driverName == DefaultSelection {
+	// The driver name can't be what we use as a default driver name
+	if driverName == DefaultSelection {
 		return false
 	}
 
 	driverMu.Lock()
-	defer driverMu.Unlock()
+
+	if !isInitialised {
+		driverMu.Unlock()
+		return false
+	}
 
 	name := libraryKey(groupName, driverName)
 
+	var fns []func(Event)
+	var event Event
 	if _, found = driverLibrary[name]; found {
 		driverLibrary[name].Default = true
+		event = Event{Op: EventDefaultChanged, Group: groupName, Name: driverName}
+		fns = watchersFor(event)
+	}
+	driverMu.Unlock()
+
+	if found {
+		notify(event, fns)
 	}
 	return found
 }
 
 func GetDefaultName(groupName string) (string, error) {
-	driverMu.Lock()
-	defer driverMu.Unlock()
+	driverMu.RLock()
+	defer driverMu.RUnlock()
 
 	lname := strings.ToLower(groupName) + NameSeparator
 	for key, driverInstance := range driverLibrary {
@@ -262,13 +551,13 @@ func GetDefaultName(groupName string) (string, error) {
 
 // Help will return a help string at the level requested
 func Help(groupName, driverName string, level int) string {
+	driverMu.RLock()
+	defer driverMu.RUnlock()
+
 	if !isInitialised {
 		return ""
 	}
 
-	driverMu.Lock()
-	defer driverMu.Unlock()
-
 	if driver, ok := driverLibrary[libraryKey(groupName, driverName)]; ok {
 		return driver.Driver.Identity(level)
 	}
@@ -281,10 +570,10 @@ func ListGroup() map[string]int {
 	var groupNames map[string]int
 	groupNames = make(map[string]int)
 
-	if isInitialised {
-		driverMu.Lock()
-		defer driverMu.Unlock()
+	driverMu.RLock()
+	defer driverMu.RUnlock()
 
+	if isInitialised {
 		for _, driverEntry := range driverLibrary {
 			groupId := driverEntry.Group
 			if _, ok := groupNames[groupId]; ok {
@@ -304,21 +593,103 @@ func libraryKey(groupName, driverName string) string {
 	return strings.ToLower(groupName) + NameSeparator + strings.ToLower(driverName)
 }
 
+// findDriver looks the driver up under a read lock, then builds the new
+// instance under a per-key lock so unrelated lookups aren't blocked while
+// this one driver's (possibly slow) New() runs.
 func findDriver(groupName, driverName string) (interface{}, error) {
-	if driverInstance, ok := driverLibrary[libraryKey(groupName, driverName)]; ok {
-		return driverInstance.Driver.New(), nil
+	driverKey := libraryKey(groupName, driverName)
+
+	driverMu.RLock()
+	driverInstance, ok := driverLibrary[driverKey]
+	driverMu.RUnlock()
+	if !ok {
+		return nil, errors.New("Invalid driver: " + groupName + ":" + driverName)
 	}
-	return nil, errors.New("Invalid driver: " + groupName + ":" + driverName)
+
+	driverLocks.Lock(driverKey)
+	defer driverLocks.Unlock(driverKey)
+
+	return driverInstance.Driver.New(), nil
 }
 
+// findDefaultDriver mirrors findDriver: the search for which driver counts as
+// the default happens under a read lock, and only the New() call itself is
+// serialised per-key.
 func findDefaultDriver(groupName string) (interface{}, error) {
+	driverMu.RLock()
+	candidates := collectDefaultCandidates(groupName)
+	driverMu.RUnlock()
+
+	driverInstance, driverKey := selectDefault(candidates)
+	if driverInstance == nil {
+		return nil, errors.New(fmt.Sprintf("No default driver set for %s", groupName))
+	}
+
+	driverLocks.Lock(driverKey)
+	defer driverLocks.Unlock(driverKey)
+
+	return driverInstance.Driver.New(), nil
+}
+
+// defaultCandidate pairs a driverMember with its library key, so selectDefault
+// can report back which key to take the per-key lock on.
+type defaultCandidate struct {
+	entry *driverMember
+	key   string
+}
+
+// collectDefaultCandidates gathers every driver selectDefault might pick as
+// groupName's default, in priority order: any explicitly marked default,
+// then the names in the group's configured SetPriority order, then (if it
+// would apply) the lone-registered-driver fallback New has always used. It
+// must be called with driverMu already held for reading; it only reads the
+// registry; the actual Probe calls happen afterwards, once the lock has been
+// released, since a driver's Probe may block on arbitrary I/O.
+func collectDefaultCandidates(groupName string) []defaultCandidate {
 	lname := strings.ToLower(groupName) + NameSeparator
-	for key, driverInstance := range driverLibrary {
-		if strings.HasPrefix(key, lname) {
-			if driverInstance.Default || len(driverLibrary) == 1 {
-				return driverInstance.Driver.New(), nil
+	var candidates []defaultCandidate
+
+	for key, entry := range driverLibrary {
+		if strings.HasPrefix(key, lname) && entry.Default {
+			candidates = append(candidates, defaultCandidate{entry, key})
+		}
+	}
+
+	for _, name := range groupPriority[strings.ToLower(groupName)] {
+		key := libraryKey(groupName, name)
+		if entry, ok := driverLibrary[key]; ok {
+			candidates = append(candidates, defaultCandidate{entry, key})
+		}
+	}
+
+	if len(driverLibrary) == 1 {
+		for key, entry := range driverLibrary {
+			if strings.HasPrefix(key, lname) {
+				candidates = append(candidates, defaultCandidate{entry, key})
 			}
 		}
 	}
-	return nil, errors.New(fmt.Sprintf("No default driver set for %d", groupName))
+
+	return candidates
+}
+
+// selectDefault returns the first candidate that probes as available. It
+// must be called without driverMu held.
+func selectDefault(candidates []defaultCandidate) (*driverMember, string) {
+	for _, candidate := range candidates {
+		if probeAvailable(candidate.entry.Driver) {
+			return candidate.entry, candidate.key
+		}
+	}
+	return nil, ""
+}
+
+// probeAvailable reports whether driver is usable. Drivers that don't
+// implement Prober are always considered available.
+func probeAvailable(driver DriverInterface) bool {
+	prober, ok := driver.(Prober)
+	if !ok {
+		return true
+	}
+	return prober.Probe() == nil
 }
@@ -2,7 +2,9 @@ package gdriver
 
 import (
 	"fmt"
+	"sync"
 	"testing"
+	"time"
 )
 
 type mockDriver struct{}
@@ -81,3 +83,322 @@ func TestRegister(t *testing.T) {
 	}
 
 }
+
+func TestUnregisterReplaceWatch(t *testing.T) {
+	var events []Event
+	Watch("watchgroup", func(event Event) {
+		events = append(events, event)
+	})
+
+	Register("watchgroup", &tDriver1{})
+	if !IsRegistered("watchgroup", "name") {
+		t.Error("Couldn't find driver using IsRegistered()")
+	}
+
+	Replace("watchgroup", &tDriver1{})
+	if !IsRegistered("watchgroup", "name") {
+		t.Error("Replace should have kept the driver registered")
+	}
+
+	if !Unregister("watchgroup", "name") {
+		t.Error("Unregister should have found and removed the driver")
+	}
+	if IsRegistered("watchgroup", "name") {
+		t.Error("Driver should no longer be registered after Unregister")
+	}
+	if Unregister("watchgroup", "name") {
+		t.Error("Unregister should return false for a driver that is not registered")
+	}
+
+	if len(events) != 3 {
+		t.Errorf("Expected 3 events, got %d", len(events))
+	} else {
+		if events[0].Op != EventRegister || events[1].Op != EventReplace || events[2].Op != EventUnregister {
+			t.Error("Events were not delivered in the expected order")
+		}
+	}
+}
+
+func TestWatchCallbackCanCallBackIntoGdriver(t *testing.T) {
+	done := make(chan bool, 1)
+
+	Watch("watchcallbackgroup", func(event Event) {
+		done <- IsRegistered("watchcallbackgroup", "name")
+	})
+
+	Register("watchcallbackgroup", &tDriver1{})
+
+	select {
+	case found := <-done:
+		if !found {
+			t.Error("IsRegistered called from within a watcher should have seen the new driver")
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Register deadlocked calling a watcher that calls back into gdriver")
+	}
+}
+
+func TestConcurrentNewDoesNotDeadlock(t *testing.T) {
+	Register("concurrentgroup", &tDriver1{})
+	Default("concurrentgroup", "name")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if _, err := New("concurrentgroup", "name"); err != nil {
+				t.Error(err.Error())
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := New("concurrentgroup", DefaultSelection); err != nil {
+				t.Error(err.Error())
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+type priorityDriver struct {
+	name      string
+	available bool
+}
+
+func (p *priorityDriver) New() interface{} { return p }
+func (p *priorityDriver) Identity(id int) string {
+	if id == IdentityName {
+		return p.name
+	}
+	return p.name
+}
+func (p *priorityDriver) Probe() error {
+	if p.available {
+		return nil
+	}
+	return fmt.Errorf("%s is unavailable", p.name)
+}
+
+func TestSetPriority(t *testing.T) {
+	Register("prioritygroup", &priorityDriver{name: "aufs", available: false})
+	Register("prioritygroup", &priorityDriver{name: "overlay2", available: true})
+	Register("prioritygroup", &priorityDriver{name: "vfs", available: true})
+
+	SetPriority("prioritygroup", []string{"aufs", "overlay2", "vfs"})
+
+	picked, err := New("prioritygroup", DefaultSelection)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if picked.(*priorityDriver).name != "overlay2" {
+		t.Errorf("expected priority fallback to skip unavailable 'aufs' and pick 'overlay2', got %q", picked.(*priorityDriver).name)
+	}
+}
+
+// slowProbeDriver's Probe() doesn't return until release is closed, so a test
+// can check that New(group, DefaultSelection) doesn't hold driverMu for the
+// duration of a slow probe.
+type slowProbeDriver struct {
+	release chan struct{}
+}
+
+func (p *slowProbeDriver) New() interface{} { return p }
+func (p *slowProbeDriver) Identity(id int) string {
+	if id == IdentityName {
+		return "slowprobe"
+	}
+	return "slow probe driver"
+}
+func (p *slowProbeDriver) Probe() error {
+	<-p.release
+	return nil
+}
+
+func TestDefaultSelectionDoesNotHoldLockDuringProbe(t *testing.T) {
+	release := make(chan struct{})
+	Register("slowprobegroup", &slowProbeDriver{release: release})
+	Default("slowprobegroup", "slowprobe")
+
+	newDone := make(chan bool, 1)
+	go func() {
+		_, err := New("slowprobegroup", DefaultSelection)
+		newDone <- err == nil
+	}()
+
+	// Give New() a chance to reach the probe and start blocking in it.
+	time.Sleep(50 * time.Millisecond)
+
+	// If the probe were still running under driverMu, this would hang until
+	// release is closed; confirm it doesn't.
+	registerDone := make(chan bool, 1)
+	go func() {
+		Register("otherslowprobegroup", &tDriver1{})
+		registerDone <- true
+	}()
+
+	select {
+	case <-registerDone:
+	case <-time.After(3 * time.Second):
+		t.Fatal("Register blocked behind an unrelated group's in-flight Probe call")
+	}
+
+	close(release)
+	if ok := <-newDone; !ok {
+		t.Error("New(group, DefaultSelection) should have succeeded once the probe completed")
+	}
+}
+
+type singletonInstance struct {
+	initOpts []interface{}
+	closed   bool
+}
+
+func (s *singletonInstance) Init(opts ...interface{}) error {
+	s.initOpts = opts
+	return nil
+}
+func (s *singletonInstance) Close() error {
+	s.closed = true
+	return nil
+}
+
+type singletonRegisterDriver struct{}
+
+func (r *singletonRegisterDriver) New() interface{} { return &singletonInstance{} }
+func (r *singletonRegisterDriver) Identity(id int) string {
+	if id == IdentityName {
+		return "singleton"
+	}
+	return "singleton driver"
+}
+
+func TestNewSingletonAndShutdown(t *testing.T) {
+	Register("singletongroup", &singletonRegisterDriver{})
+
+	first, err := NewSingleton("singletongroup", "singleton", "rootPath")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	second, err := NewSingleton("singletongroup", "singleton", "ignored")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if first != second {
+		t.Error("NewSingleton should return the same cached instance on a second call")
+	}
+
+	instance := first.(*singletonInstance)
+	if len(instance.initOpts) != 1 || instance.initOpts[0] != "rootPath" {
+		t.Errorf("expected Init to be called with the first call's opts, got %v", instance.initOpts)
+	}
+
+	if err := Shutdown("singletongroup"); err != nil {
+		t.Fatal(err.Error())
+	}
+	if !instance.closed {
+		t.Error("Shutdown should have closed the singleton")
+	}
+
+	third, err := NewSingleton("singletongroup", "singleton")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if third == first {
+		t.Error("NewSingleton should build a fresh instance after Shutdown")
+	}
+}
+
+func TestUnregisterRefusesLiveSingleton(t *testing.T) {
+	Register("unregistersingletongroup", &singletonRegisterDriver{})
+
+	instance, err := NewSingleton("unregistersingletongroup", "singleton")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if Unregister("unregistersingletongroup", "singleton") {
+		t.Error("Unregister should have refused to remove a driver with a live singleton")
+	}
+	if !IsRegistered("unregistersingletongroup", "singleton") {
+		t.Error("driver should still be registered after the refused Unregister")
+	}
+
+	if err := Shutdown("unregistersingletongroup"); err != nil {
+		t.Fatal(err.Error())
+	}
+	if !instance.(*singletonInstance).closed {
+		t.Error("Shutdown should have closed the singleton")
+	}
+
+	if !Unregister("unregistersingletongroup", "singleton") {
+		t.Error("Unregister should succeed once the singleton has been shut down")
+	}
+}
+
+type blockingInstance struct{ closed bool }
+
+func (b *blockingInstance) Close() error {
+	b.closed = true
+	return nil
+}
+
+// blockingDriver's New() doesn't return until release is closed, so a test
+// can park a NewSingleton call mid-construction and race Unregister against it.
+type blockingDriver struct{ release chan struct{} }
+
+func (d *blockingDriver) New() interface{} {
+	<-d.release
+	return &blockingInstance{}
+}
+func (d *blockingDriver) Identity(id int) string {
+	if id == IdentityName {
+		return "blocking"
+	}
+	return "blocking driver"
+}
+
+func TestUnregisterSerializesWithInFlightNewSingleton(t *testing.T) {
+	release := make(chan struct{})
+	Register("blockinggroup", &blockingDriver{release: release})
+
+	singletonDone := make(chan interface{}, 1)
+	go func() {
+		instance, err := NewSingleton("blockinggroup", "blocking")
+		if err != nil {
+			t.Error(err.Error())
+		}
+		singletonDone <- instance
+	}()
+
+	// Give NewSingleton a chance to take the per-key lock and block inside
+	// New(), Singleton still nil, before Unregister gets a look at it.
+	time.Sleep(50 * time.Millisecond)
+
+	unregisterDone := make(chan bool, 1)
+	go func() {
+		unregisterDone <- Unregister("blockinggroup", "blocking")
+	}()
+
+	// Give Unregister a chance to queue up behind the per-key lock before
+	// letting New() return.
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	instance := <-singletonDone
+	if unregistered := <-unregisterDone; unregistered {
+		t.Error("Unregister should not succeed against a driver that NewSingleton just finished turning into a live singleton")
+	}
+	if !IsRegistered("blockinggroup", "blocking") {
+		t.Error("driver should remain registered since Unregister was refused")
+	}
+
+	if err := Shutdown("blockinggroup"); err != nil {
+		t.Fatal(err.Error())
+	}
+	if !instance.(*blockingInstance).closed {
+		t.Error("Shutdown should have closed the singleton NewSingleton built concurrently with Unregister")
+	}
+}
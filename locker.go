@@ -0,0 +1,58 @@
+package gdriver
+
+import "sync"
+
+// keyLocker hands out one mutex per key, created on first use and discarded
+// once nothing references it any more. It is modelled on Docker's
+// pkg/locker.Locker: driverMu only needs to be held long enough to look a key
+// up, while the potentially slow work of constructing or initialising a
+// driver for that key is serialised against itself without blocking lookups
+// for unrelated keys.
+type keyLocker struct {
+	mu    sync.Mutex
+	locks map[string]*refCountedMutex
+}
+
+// refCountedMutex is a mutex plus the number of goroutines currently waiting
+// on or holding it, so that keyLocker knows when it is safe to drop the entry.
+type refCountedMutex struct {
+	mu   sync.Mutex
+	refs int
+}
+
+func newKeyLocker() *keyLocker {
+	return &keyLocker{locks: make(map[string]*refCountedMutex)}
+}
+
+// Lock acquires the mutex associated with key, creating it if this is the
+// first caller to reference it.
+func (l *keyLocker) Lock(key string) {
+	l.mu.Lock()
+	rm, ok := l.locks[key]
+	if !ok {
+		rm = &refCountedMutex{}
+		l.locks[key] = rm
+	}
+	rm.refs++
+	l.mu.Unlock()
+
+	rm.mu.Lock()
+}
+
+// Unlock releases the mutex associated with key. Once the last holder has
+// released it, the entry is removed so the map doesn't grow without bound.
+func (l *keyLocker) Unlock(key string) {
+	l.mu.Lock()
+	rm, ok := l.locks[key]
+	if !ok {
+		l.mu.Unlock()
+		return
+	}
+	rm.refs--
+	if rm.refs <= 0 {
+		delete(l.locks, key)
+	}
+	l.mu.Unlock()
+
+	rm.mu.Unlock()
+}
@@ -0,0 +1,94 @@
+package gdriver
+
+import "fmt"
+
+// TypedDriver is the generic counterpart to DriverInterface: New returns the
+// concrete driver type T directly instead of interface{}, so callers using a
+// Registry[T] never need the ndrive.(*mockDriver) style cast the package-level
+// API requires.
+type TypedDriver[T any] interface {
+	New() T
+	Identity(int) string
+}
+
+// Registry is a group-scoped, compile-time-typed view onto the same
+// underlying store New, Register and Default use. It exists for callers who
+// know T for a given group up front and would rather not cast; it delegates
+// every call to the package-level functions, so a Registry and the untyped
+// API can be mixed freely against the same group.
+type Registry[T any] struct {
+	group string
+}
+
+// NewRegistry returns a Registry scoped to group.
+func NewRegistry[T any](group string) *Registry[T] {
+	return &Registry[T]{group: group}
+}
+
+// Register adds d to the registry's group, the same as calling the
+// package-level Register with an adapter around d.
+func (r *Registry[T]) Register(d TypedDriver[T]) {
+	Register(r.group, typedAdapter[T]{d})
+}
+
+// New builds a new instance of the named driver, reporting an error if the
+// driver is not registered or does not actually produce a T.
+func (r *Registry[T]) New(name string) (T, error) {
+	return r.newInstance(name)
+}
+
+// MustNew is a wrapper around New that panics instead of returning an error.
+func (r *Registry[T]) MustNew(name string) T {
+	d, err := r.New(name)
+	if err != nil {
+		panic(err.Error())
+	}
+	return d
+}
+
+// Default builds the group's default driver, the typed equivalent of calling
+// New(group, DefaultSelection).
+func (r *Registry[T]) Default() (T, error) {
+	return r.newInstance(DefaultSelection)
+}
+
+// MustDefault is a wrapper around Default that panics instead of returning an
+// error.
+func (r *Registry[T]) MustDefault() T {
+	d, err := r.Default()
+	if err != nil {
+		panic(err.Error())
+	}
+	return d
+}
+
+// Help returns the identity string for name at level, the typed registry's
+// equivalent of the package-level Help function.
+func (r *Registry[T]) Help(name string, level int) string {
+	return Help(r.group, name, level)
+}
+
+func (r *Registry[T]) newInstance(name string) (T, error) {
+	var zero T
+
+	instance, err := New(r.group, name)
+	if err != nil {
+		return zero, err
+	}
+
+	typed, ok := instance.(T)
+	if !ok {
+		return zero, fmt.Errorf("gdriver: driver %q in group %q did not return a %T", name, r.group, zero)
+	}
+	return typed, nil
+}
+
+// typedAdapter makes a TypedDriver[T] satisfy DriverInterface so it can be
+// stored in the same untyped registry every other driver uses.
+type typedAdapter[T any] struct {
+	driver TypedDriver[T]
+}
+
+func (a typedAdapter[T]) New() interface{} { return a.driver.New() }
+
+func (a typedAdapter[T]) Identity(id int) string { return a.driver.Identity(id) }
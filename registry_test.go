@@ -0,0 +1,41 @@
+package gdriver
+
+import "testing"
+
+type typedMockDriver struct{ greeting string }
+
+func (t *typedMockDriver) Greet() string { return t.greeting }
+
+type typedRegisterMockDriver struct{}
+
+func (r *typedRegisterMockDriver) New() *typedMockDriver {
+	return &typedMockDriver{greeting: "hello"}
+}
+func (r *typedRegisterMockDriver) Identity(id int) string {
+	if id == IdentityName {
+		return "typedname"
+	}
+	return "typed"
+}
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry[*typedMockDriver]("typedgroup")
+	registry.Register(&typedRegisterMockDriver{})
+
+	driver, err := registry.New("typedname")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if driver.Greet() != "hello" {
+		t.Error("Registry.New did not return the typed driver instance")
+	}
+
+	if !Default("typedgroup", "typedname") {
+		t.Fatal("Default should have found the typed driver")
+	}
+
+	def := registry.MustDefault()
+	if def.Greet() != "hello" {
+		t.Error("Registry.MustDefault did not return the typed driver instance")
+	}
+}
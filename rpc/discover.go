@@ -0,0 +1,27 @@
+package rpc
+
+import "path/filepath"
+
+// DefaultPluginDir is the conventional directory to pass to Discover when
+// looking for plugin sockets, analogous to Docker's /run/docker/plugins
+// discovery convention. Discover itself scans only the dir it is given.
+const DefaultPluginDir = "/etc/gdriver/plugins.d"
+
+// Discover scans dir for "*.sock" files and calls RegisterRemote(group, ...)
+// for each one it finds. It keeps going on individual failures so that one
+// unreachable or misbehaving plugin doesn't stop the rest from being picked
+// up, but returns the first error it encountered once the sweep is done.
+func Discover(group, dir string) error {
+	sockets, err := filepath.Glob(filepath.Join(dir, "*.sock"))
+	if err != nil {
+		return err
+	}
+
+	var firstErr error
+	for _, socketPath := range sockets {
+		if err := RegisterRemote(group, socketPath); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,70 @@
+package rpc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// formatHandle turns a sequence number into the opaque string handed back to
+// clients by the New RPC method.
+func formatHandle(seq uint64) string {
+	return "h" + strconv.FormatUint(seq, 10)
+}
+
+// invokeMethod looks up method by name on instance and calls it, decoding args
+// into the method's single parameter when one is expected and re-encoding
+// whatever it returns. It supports the method shapes a driver realistically
+// exposes: zero or one argument, and a single result optionally followed by
+// an error.
+func invokeMethod(instance interface{}, method string, args RawMessage) (RawMessage, error) {
+	value := reflect.ValueOf(instance)
+	fn := value.MethodByName(method)
+	if !fn.IsValid() {
+		return nil, fmt.Errorf("rpc: instance has no method %q", method)
+	}
+
+	fnType := fn.Type()
+	var in []reflect.Value
+
+	switch fnType.NumIn() {
+	case 0:
+		if len(args) > 0 {
+			return nil, fmt.Errorf("rpc: method %q takes no arguments", method)
+		}
+	case 1:
+		argPtr := reflect.New(fnType.In(0))
+		if len(args) > 0 {
+			if err := json.Unmarshal(args, argPtr.Interface()); err != nil {
+				return nil, err
+			}
+		}
+		in = []reflect.Value{argPtr.Elem()}
+	default:
+		return nil, fmt.Errorf("rpc: method %q takes too many arguments for Invoke", method)
+	}
+
+	out := fn.Call(in)
+
+	var result interface{}
+	switch len(out) {
+	case 0:
+		return nil, nil
+	case 1:
+		if err, ok := out[0].Interface().(error); ok {
+			return nil, err
+		}
+		result = out[0].Interface()
+	case 2:
+		if err, ok := out[1].Interface().(error); ok && err != nil {
+			return nil, err
+		}
+		result = out[0].Interface()
+	default:
+		return nil, errors.New("rpc: method " + method + " returns too many values for Invoke")
+	}
+
+	return json.Marshal(result)
+}
@@ -0,0 +1,194 @@
+// Package rpc lets a gdriver driver live in a separate process and still be
+// registered into the normal in-process registry. A plugin process calls
+// Serve on a listening Unix socket to expose its driver; the host process
+// calls RegisterRemote (or Discover, to pick up every socket in a well-known
+// directory) to dial that socket and install a proxy DriverInterface into
+// gdriver, exactly as if the driver had been registered locally.
+//
+// The wire protocol is plain JSON-RPC (net/rpc/jsonrpc) over the socket, with
+// three methods exposed by the plugin side:
+//
+//	Identity(level int) string
+//			Returns the driver's identity string, the same value a local
+//			DriverInterface would return.
+//
+//	New() (handle string, err error)
+//			Asks the plugin to construct a new driver instance and returns an
+//			opaque handle that identifies it for later Invoke calls.
+//
+//	Invoke(handle, method string, args json.RawMessage) (json.RawMessage, error)
+//			Calls method on the instance named by handle, passing args as its
+//			single JSON-encoded parameter, and returns the JSON-encoded result.
+//
+// This mirrors the split Docker uses for out-of-process volume/network
+// plugins: a thin local proxy that forwards every call across a socket to
+// the real implementation running elsewhere.
+package rpc
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"sync"
+
+	"github.com/510453684/gdriver"
+)
+
+// driverService is the net/rpc receiver registered on the plugin side. It
+// wraps the real driver and keeps track of every instance New() has handed
+// out so that later Invoke calls can be routed to the right one.
+type driverService struct {
+	driver gdriver.DriverInterface
+
+	mu        sync.Mutex
+	instances map[string]interface{}
+	nextID    uint64
+}
+
+// InvokeRequest is the argument type for the Invoke RPC method.
+type InvokeRequest struct {
+	Handle string
+	Method string
+	Args   RawMessage
+}
+
+// RawMessage is a JSON-encoded payload passed across the wire. It is an alias
+// for encoding/json.RawMessage, not a plain []byte, so that net/rpc/jsonrpc's
+// own use of encoding/json to marshal the envelope embeds it as literal JSON
+// rather than base64-encoding it — a non-Go plugin speaking the documented
+// wire protocol needs the former to interoperate.
+type RawMessage = json.RawMessage
+
+// Identity forwards to the wrapped driver's Identity method.
+func (s *driverService) Identity(level int, reply *string) error {
+	*reply = s.driver.Identity(level)
+	return nil
+}
+
+// New asks the wrapped driver for a new instance and returns a handle that
+// Invoke can later use to reach it.
+func (s *driverService) New(_ struct{}, reply *string) error {
+	instance := s.driver.New()
+
+	s.mu.Lock()
+	s.nextID++
+	handle := formatHandle(s.nextID)
+	s.instances[handle] = instance
+	s.mu.Unlock()
+
+	*reply = handle
+	return nil
+}
+
+// Invoke calls method on the instance named by req.Handle using reflection,
+// passing req.Args as its single decoded argument when present.
+func (s *driverService) Invoke(req InvokeRequest, reply *RawMessage) error {
+	s.mu.Lock()
+	instance, ok := s.instances[req.Handle]
+	s.mu.Unlock()
+	if !ok {
+		return errors.New("rpc: unknown driver handle " + req.Handle)
+	}
+
+	result, err := invokeMethod(instance, req.Method, req.Args)
+	if err != nil {
+		return err
+	}
+	*reply = result
+	return nil
+}
+
+// Serve registers driver as a net/rpc-over-JSON service and blocks, handing
+// every accepted connection on listener its own codec so that multiple
+// clients (or a client and a plugin discovery sweep) can use the socket
+// concurrently. It returns when the listener is closed.
+func Serve(listener net.Listener, driver gdriver.DriverInterface) error {
+	server := rpc.NewServer()
+	service := &driverService{driver: driver, instances: make(map[string]interface{})}
+	if err := server.RegisterName("Driver", service); err != nil {
+		return err
+	}
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go server.ServeCodec(jsonrpc.NewServerCodec(conn))
+	}
+}
+
+// remoteDriver is the DriverInterface installed into gdriver's registry on
+// the host side. Its New() dials back over the already-connected client to
+// ask the plugin process to construct the real instance.
+type remoteDriver struct {
+	client *rpc.Client
+	name   string
+}
+
+func (r *remoteDriver) Identity(level int) string {
+	if level == gdriver.IdentityName {
+		return r.name
+	}
+	var reply string
+	if err := r.client.Call("Driver.Identity", level, &reply); err != nil {
+		return gdriver.IdentityUnknown
+	}
+	return reply
+}
+
+// New asks the remote plugin process to construct a driver instance and
+// returns a RemoteInstance proxy for invoking its methods.
+func (r *remoteDriver) New() interface{} {
+	var handle string
+	if err := r.client.Call("Driver.New", struct{}{}, &handle); err != nil {
+		return nil
+	}
+	return &RemoteInstance{client: r.client, handle: handle}
+}
+
+// RemoteInstance is the handle-side proxy returned by a remote driver's New().
+// Callers that know the remote method name they want can reach it with
+// Invoke; it is up to the caller to marshal/unmarshal args and results.
+type RemoteInstance struct {
+	client *rpc.Client
+	handle string
+}
+
+// Invoke calls method on the remote instance, passing args as its JSON-encoded
+// argument, and returns the JSON-encoded result.
+func (ri *RemoteInstance) Invoke(method string, args RawMessage) (RawMessage, error) {
+	var reply RawMessage
+	req := InvokeRequest{Handle: ri.handle, Method: method, Args: args}
+	if err := ri.client.Call("Driver.Invoke", req, &reply); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// RegisterRemote dials socketPath, learns the remote driver's name by calling
+// Identity(gdriver.IdentityName), and installs a proxy DriverInterface for it
+// into group, exactly as Register would for a local driver.
+func RegisterRemote(group, socketPath string) error {
+	conn, err := net.Dial("unix", socketPath)
+	if err != nil {
+		return err
+	}
+
+	client := jsonrpc.NewClient(conn)
+
+	var name string
+	if err := client.Call("Driver.Identity", gdriver.IdentityName, &name); err != nil {
+		client.Close()
+		return err
+	}
+	if name == "" || name == gdriver.DefaultSelection {
+		client.Close()
+		return errors.New("rpc: plugin at " + socketPath + " did not supply a valid name")
+	}
+
+	gdriver.Register(group, &remoteDriver{client: client, name: name})
+	return nil
+}
@@ -0,0 +1,89 @@
+package rpc
+
+import (
+	"encoding/json"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/510453684/gdriver"
+)
+
+type echoInstance struct{}
+
+func (e *echoInstance) Echo(msg string) string { return msg }
+
+type echoDriver struct{}
+
+func (d *echoDriver) New() interface{} { return &echoInstance{} }
+func (d *echoDriver) Identity(id int) string {
+	if id == gdriver.IdentityName {
+		return "echo"
+	}
+	return "echo driver"
+}
+
+func TestRegisterRemote(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "echo.sock")
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	go Serve(listener, &echoDriver{})
+
+	if err := RegisterRemote("rpctest", socketPath); err != nil {
+		t.Fatal(err)
+	}
+
+	if !gdriver.IsRegistered("rpctest", "echo") {
+		t.Error("RegisterRemote did not install the remote driver's name")
+	}
+
+	instance, err := gdriver.New("rpctest", "echo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	remote, ok := instance.(*RemoteInstance)
+	if !ok {
+		t.Fatalf("expected *RemoteInstance, got %T", instance)
+	}
+
+	args, _ := json.Marshal("hello")
+	reply, err := remote.Invoke("Echo", args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got string
+	if err := json.Unmarshal(reply, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got != "hello" {
+		t.Errorf("expected %q, got %q", "hello", got)
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	dir := t.TempDir()
+	socketPath := filepath.Join(dir, "echo.sock")
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	go Serve(listener, &echoDriver{})
+
+	if err := Discover("discoverytest", dir); err != nil {
+		t.Fatal(err)
+	}
+
+	if !gdriver.IsRegistered("discoverytest", "echo") {
+		t.Error("Discover did not register the plugin found in dir")
+	}
+}